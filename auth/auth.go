@@ -0,0 +1,180 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+// User project / copyright / usage information
+// OAuth2/OIDC authentication for the Films API, backed by goth and a
+// single gorilla session store (one cookie, one store, no separate
+// session backend)
+
+package auth
+
+import (
+  "net/http"
+
+  "github.com/gorilla/mux"
+  "github.com/gorilla/sessions"
+  "github.com/markbates/goth"
+  "github.com/markbates/goth/gothic"
+)
+
+// SessionName is the single cookie used for both login state and scopes
+const SessionName string = "films_session"
+
+// Scope names used to gate read-only vs. mutating film operations
+const (
+  ScopeRead  string = "films:read"
+  ScopeWrite string = "films:write"
+)
+
+// Config configures which OAuth2/OIDC providers are enabled and where
+// session state is stored
+type Config struct {
+  Store         sessions.Store
+  SessionSecret string
+  // ProviderScopes maps a goth provider name (e.g. "google", "github",
+  // "gitlab") to the space-separated set of scopes granted to sessions
+  // authenticated through it. A provider absent from this map only
+  // grants ScopeRead.
+  ProviderScopes map[string]string
+}
+
+// Auth wires goth providers into FilmsApp.Router
+type Auth struct {
+  store          sessions.Store
+  providerScopes map[string]string
+}
+
+// New registers provider, google/github/gitlab goth.Providers, and
+// returns an Auth ready to protect routes
+func New(cfg Config, providers ...goth.Provider) *Auth {
+  gothic.Store = cfg.Store
+  goth.UseProviders(providers...)
+  return &Auth{store: cfg.Store, providerScopes: cfg.ProviderScopes}
+}
+
+// scopesFor returns the scopes granted to sessions authenticated through
+// provider, defaulting to read-only access
+func (a *Auth) scopesFor(provider string) string {
+  if s, ok := a.providerScopes[provider]; ok {
+    return s
+  }
+  return ScopeRead
+}
+
+// Register adds /auth/{provider}/login, /auth/{provider}/callback, and
+// /auth/logout to router
+func (a *Auth) Register(router *mux.Router) {
+  router.HandleFunc("/auth/{provider}/login", a.beginAuth)
+  router.HandleFunc("/auth/{provider}/callback", a.completeAuth)
+  router.HandleFunc("/auth/logout", a.logout)
+}
+
+func (a *Auth) beginAuth(w http.ResponseWriter, r *http.Request) {
+  gothic.BeginAuthHandler(w, withProviderParam(r))
+}
+
+func (a *Auth) completeAuth(w http.ResponseWriter, r *http.Request) {
+  user, err := gothic.CompleteUserAuth(w, withProviderParam(r))
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusUnauthorized)
+    return
+  }
+
+  session, _ := a.store.Get(r, SessionName)
+  session.Values["email"] = user.Email
+  session.Values["provider_id"] = user.UserID
+  session.Values["provider"] = user.Provider
+  session.Values["scopes"] = a.scopesFor(user.Provider)
+  if err := session.Save(r, w); err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+
+  http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (a *Auth) logout(w http.ResponseWriter, r *http.Request) {
+  session, _ := a.store.Get(r, SessionName)
+  session.Options.MaxAge = -1
+  session.Save(r, w)
+  http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// FilmsKey resolves the FilmsKey path variable to the authenticated
+// user's email, falling back to their provider ID when no email is set
+func (a *Auth) FilmsKey(r *http.Request) (string, bool) {
+  session, err := a.store.Get(r, SessionName)
+  if err != nil {
+    return "", false
+  }
+  if email, ok := session.Values["email"].(string); ok && email != "" {
+    return email, true
+  }
+  if id, ok := session.Values["provider_id"].(string); ok && id != "" {
+    return id, true
+  }
+  return "", false
+}
+
+// RequireAuth is mux middleware gating access to scope. Requests without
+// a valid session, or without scope, are rejected with 401/403
+func (a *Auth) RequireAuth(scope string) mux.MiddlewareFunc {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      session, err := a.store.Get(r, SessionName)
+      if err != nil {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+      }
+      if _, ok := session.Values["email"]; !ok {
+        if _, ok := session.Values["provider_id"]; !ok {
+          http.Error(w, "unauthorized", http.StatusUnauthorized)
+          return
+        }
+      }
+      if scope != "" {
+        // Default-deny: a missing or empty scopes value never grants
+        // access to a route that requires one.
+        scopes, _ := session.Values["scopes"].(string)
+        if !hasScope(scopes, scope) {
+          http.Error(w, "forbidden", http.StatusForbidden)
+          return
+        }
+      }
+      next.ServeHTTP(w, r)
+    })
+  }
+}
+
+func hasScope(scopes, want string) bool {
+  for _, s := range splitScopes(scopes) {
+    if s == want {
+      return true
+    }
+  }
+  return false
+}
+
+func splitScopes(scopes string) []string {
+  var out []string
+  start := 0
+  for i := 0; i <= len(scopes); i++ {
+    if i == len(scopes) || scopes[i] == ' ' {
+      if i > start {
+        out = append(out, scopes[start:i])
+      }
+      start = i + 1
+    }
+  }
+  return out
+}
+
+func withProviderParam(r *http.Request) *http.Request {
+  q := r.URL.Query()
+  q.Set("provider", mux.Vars(r)["provider"])
+  r.URL.RawQuery = q.Encode()
+  return r
+}
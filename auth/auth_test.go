@@ -0,0 +1,87 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+package auth
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "testing"
+
+  "github.com/gorilla/mux"
+  "github.com/gorilla/sessions"
+)
+
+func TestHasScope(t *testing.T) {
+  cases := []struct {
+    scopes string
+    want   string
+    has    bool
+  }{
+    {"films:read films:write", ScopeWrite, true},
+    {"films:read", ScopeWrite, false},
+    {"", ScopeWrite, false},
+  }
+  for _, c := range cases {
+    if got := hasScope(c.scopes, c.want); got != c.has {
+      t.Errorf("hasScope(%q, %q) = %v, want %v", c.scopes, c.want, got, c.has)
+    }
+  }
+}
+
+func newTestAuth() (*Auth, sessions.Store) {
+  store := sessions.NewCookieStore([]byte("test-secret"))
+  return New(Config{Store: store, ProviderScopes: map[string]string{
+    "github": ScopeRead + " " + ScopeWrite,
+  }}), store
+}
+
+func TestRequireAuthDefaultDeniesMissingScope(t *testing.T) {
+  a, store := newTestAuth()
+
+  router := mux.NewRouter()
+  protected := router.PathPrefix("/films").Subrouter()
+  protected.Use(a.RequireAuth(ScopeWrite))
+  protected.HandleFunc("/{key}", func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusOK)
+  })
+
+  req := httptest.NewRequest(http.MethodPost, "/films/abc", nil)
+  session, _ := store.Get(req, SessionName)
+  session.Values["email"] = "user@example.com"
+  session.Values["scopes"] = ScopeRead // read-only session, no write scope
+  rec := httptest.NewRecorder()
+  session.Save(req, rec)
+  for _, c := range rec.Result().Cookies() {
+    req.AddCookie(c)
+  }
+
+  rec = httptest.NewRecorder()
+  router.ServeHTTP(rec, req)
+
+  if rec.Code != http.StatusForbidden {
+    t.Errorf("status = %d, want %d (scope not granted should be forbidden)", rec.Code, http.StatusForbidden)
+  }
+}
+
+func TestRequireAuthRejectsUnauthenticated(t *testing.T) {
+  a, _ := newTestAuth()
+
+  router := mux.NewRouter()
+  protected := router.PathPrefix("/films").Subrouter()
+  protected.Use(a.RequireAuth(ScopeRead))
+  protected.HandleFunc("/{key}", func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusOK)
+  })
+
+  req := httptest.NewRequest(http.MethodGet, "/films/abc", nil)
+  rec := httptest.NewRecorder()
+  router.ServeHTTP(rec, req)
+
+  if rec.Code != http.StatusUnauthorized {
+    t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+  }
+}
@@ -0,0 +1,66 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+// User project / copyright / usage information
+// Read-through cache layer sitting in front of the films database
+
+package cache
+
+import (
+  "context"
+  "time"
+)
+
+// Recorder receives hit/miss notifications so the metrics subsystem can
+// surface cache hit/miss ratios on /metrics. metrics.Metrics implements
+// this interface
+type Recorder interface {
+  Hit()
+  Miss()
+}
+
+// noopRecorder is used when New is called with a nil Recorder
+type noopRecorder struct{}
+
+func (noopRecorder) Hit()  {}
+func (noopRecorder) Miss() {}
+
+// Cache is the interface FilmsApp depends on, so a Redis-backed cache can
+// be swapped for an in-process LRU or a galaxycache-style peer-sharded
+// cache without touching the handlers
+type Cache interface {
+  Get(ctx context.Context, key string) (string, bool, error)
+  Set(ctx context.Context, key string, value string, ttl time.Duration) error
+  Delete(ctx context.Context, key string) error
+  // Healthy reports whether the cache backend can currently serve requests
+  Healthy(ctx context.Context) bool
+}
+
+// Config configures a cache backend. FilmsApp fills it from
+// config.CacheConfig (CACHE_HOST, CACHE_PORT, CACHE_PASSWORD, CACHE_TTL)
+type Config struct {
+  Host     string
+  Port     string
+  Password string
+  TTL      time.Duration
+}
+
+// degradedCache is returned by New when the configured backend cannot be
+// reached so handlers keep working, just always falling through to the DB
+type degradedCache struct{}
+
+func (degradedCache) Get(ctx context.Context, key string) (string, bool, error) { return "", false, nil }
+func (degradedCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+  return nil
+}
+func (degradedCache) Delete(ctx context.Context, key string) error { return nil }
+func (degradedCache) Healthy(ctx context.Context) bool             { return false }
+
+// Degraded returns a no-op Cache used when Redis is unavailable, so reads
+// gracefully fall back to Postgres/CockroachDB instead of failing
+func Degraded() Cache {
+  return degradedCache{}
+}
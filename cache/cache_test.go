@@ -0,0 +1,27 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+package cache
+
+import (
+  "context"
+  "testing"
+)
+
+func TestDegradedCacheAlwaysMisses(t *testing.T) {
+  c := Degraded()
+  ctx := context.Background()
+
+  if _, ok, err := c.Get(ctx, "key"); ok || err != nil {
+    t.Errorf("Get on degraded cache = (_, %v, %v), want (_, false, nil)", ok, err)
+  }
+  if err := c.Set(ctx, "key", "value", 0); err != nil {
+    t.Errorf("Set on degraded cache = %v, want nil", err)
+  }
+  if c.Healthy(ctx) {
+    t.Error("Healthy on degraded cache = true, want false")
+  }
+}
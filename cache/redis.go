@@ -0,0 +1,84 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+package cache
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "github.com/go-redis/redis/v8"
+)
+
+// RedisCache is the default Cache implementation, backed by a single
+// Redis instance
+type RedisCache struct {
+  client   *redis.Client
+  ttl      time.Duration
+  recorder Recorder
+}
+
+// New connects to Redis using cfg and returns a ready-to-use Cache. If
+// Redis cannot be reached, a degraded no-op cache is returned instead so
+// callers keep working off the database alone. rec receives a Hit/Miss
+// notification per Get call on the Redis-backed cache; the degraded cache
+// records nothing. Pass nil to skip recording (e.g. in tests)
+func New(cfg Config, rec Recorder) Cache {
+  client := redis.NewClient(&redis.Options{
+    Addr:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
+    Password: cfg.Password,
+  })
+
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  if err := client.Ping(ctx).Err(); err != nil {
+    return Degraded()
+  }
+
+  ttl := cfg.TTL
+  if ttl == 0 {
+    ttl = 5 * time.Minute
+  }
+  if rec == nil {
+    rec = noopRecorder{}
+  }
+  return &RedisCache{client: client, ttl: ttl, recorder: rec}
+}
+
+// Get looks up key, reporting a hit/miss to the configured Recorder
+func (c *RedisCache) Get(ctx context.Context, key string) (string, bool, error) {
+  v, err := c.client.Get(ctx, key).Result()
+  if err == redis.Nil {
+    c.recorder.Miss()
+    return "", false, nil
+  }
+  if err != nil {
+    c.recorder.Miss()
+    return "", false, err
+  }
+  c.recorder.Hit()
+  return v, true, nil
+}
+
+// Set writes key with ttl, falling back to the cache's configured
+// default TTL when ttl is zero
+func (c *RedisCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+  if ttl == 0 {
+    ttl = c.ttl
+  }
+  return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Delete invalidates key, used by write handlers after an update
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+  return c.client.Del(ctx, key).Err()
+}
+
+// Healthy pings Redis so the readiness probe can report cache status
+func (c *RedisCache) Healthy(ctx context.Context) bool {
+  return c.client.Ping(ctx).Err() == nil
+}
@@ -0,0 +1,66 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+// User project / copyright / usage information
+// Standalone worker binary: connects to the same DB as the API, pulls
+// jobs, and runs registered handlers. Lets API pods and worker pods be
+// scaled independently in k8s
+
+package main
+
+import (
+  "context"
+  "database/sql"
+  "log"
+  "os"
+  "os/signal"
+  "time"
+
+  _ "github.com/lib/pq"
+  "github.com/pavedroad-io/films/config"
+  "github.com/pavedroad-io/films/worker"
+)
+
+func main() {
+  cfg, err := config.Load(os.Getenv("FILMS_CONFIG_FILE"), os.Args[1:])
+  if err != nil {
+    log.Fatalf("config: %v", err)
+  }
+
+  dbCfg := cfg.DB()
+  db, err := sql.Open(dbCfg.DBDriver, config.DSN(dbCfg))
+  if err != nil {
+    log.Fatalf("db: %v", err)
+  }
+  defer db.Close()
+
+  q := worker.New(db)
+  w := worker.NewWorker(q, 2*time.Second)
+  w.Register("poster_art", fetchPosterArt)
+  w.Register("transcode_metadata", transcodeMetadata)
+  w.Register("import_catalog", importCatalog)
+
+  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+  defer stop()
+
+  log.Printf("worker: polling for jobs every %s", 2*time.Second)
+  w.Run(ctx)
+}
+
+func fetchPosterArt(ctx context.Context, job *worker.Job) (interface{}, error) {
+  // TODO: call out to the configured poster art provider
+  return map[string]string{"status": "fetched"}, nil
+}
+
+func transcodeMetadata(ctx context.Context, job *worker.Job) (interface{}, error) {
+  // TODO: normalize incoming catalog metadata
+  return map[string]string{"status": "transcoded"}, nil
+}
+
+func importCatalog(ctx context.Context, job *worker.Job) (interface{}, error) {
+  // TODO: stream records from the external catalog API
+  return map[string]string{"status": "imported"}, nil
+}
@@ -0,0 +1,336 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+// User project / copyright / usage information
+// Layered configuration loader for FilmsApp, inspired by Vimeo's dials.
+// Precedence, lowest to highest: struct defaults -> config file -> environment -> flags.
+
+package config
+
+import (
+  "encoding/json"
+  "flag"
+  "fmt"
+  "net/url"
+  "os"
+  "os/signal"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "sync/atomic"
+  "syscall"
+  "time"
+
+  "github.com/BurntSushi/toml"
+  "gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is prepended to the environment variable names FilmsConfig
+// reads, except the cache settings, which use unprefixed CACHE_* names
+const EnvPrefix string = "FILMS_"
+
+// DatabaseConfig holds database connection settings
+type DatabaseConfig struct {
+  Username string `json:"username" yaml:"username" toml:"username"`
+  Password string `json:"password" yaml:"password" toml:"password"`
+  Database string `json:"database" yaml:"database" toml:"database"`
+  SSLMode  string `json:"sslMode" yaml:"sslMode" toml:"sslMode"`
+  DBDriver string `json:"dbDriver" yaml:"dbDriver" toml:"dbDriver"`
+  IP       string `json:"ip" yaml:"ip" toml:"ip"`
+  Port     string `json:"port" yaml:"port" toml:"port"`
+}
+
+// HTTPConfig holds HTTP server settings
+type HTTPConfig struct {
+  IP              string        `json:"ip" yaml:"ip" toml:"ip"`
+  Port            string        `json:"port" yaml:"port" toml:"port"`
+  ShutdownTimeout time.Duration `json:"shutdownTimeout" yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+  ReadTimeout     time.Duration `json:"readTimeout" yaml:"readTimeout" toml:"readTimeout"`
+  WriteTimeout    time.Duration `json:"writeTimeout" yaml:"writeTimeout" toml:"writeTimeout"`
+  ListenString    string        `json:"listenString" yaml:"listenString" toml:"listenString"`
+  LogPath         string        `json:"logPath" yaml:"logPath" toml:"logPath"`
+}
+
+// LogConfig holds logging subsystem settings
+type LogConfig struct {
+  Level      string `json:"level" yaml:"level" toml:"level"`
+  Format     string `json:"format" yaml:"format" toml:"format"`
+  MaxSize    int    `json:"maxSize" yaml:"maxSize" toml:"maxSize"`
+  MaxAge     int    `json:"maxAge" yaml:"maxAge" toml:"maxAge"`
+  MaxBackups int    `json:"maxBackups" yaml:"maxBackups" toml:"maxBackups"`
+  Compress   bool   `json:"compress" yaml:"compress" toml:"compress"`
+}
+
+// CacheConfig holds the read-through cache subsystem settings
+type CacheConfig struct {
+  Host     string        `json:"host" yaml:"host" toml:"host"`
+  Port     string        `json:"port" yaml:"port" toml:"port"`
+  Password string        `json:"password" yaml:"password" toml:"password"`
+  TTL      time.Duration `json:"ttl" yaml:"ttl" toml:"ttl"`
+}
+
+// AuthConfig holds the OAuth2/OIDC session settings
+type AuthConfig struct {
+  SessionSecret string `json:"sessionSecret" yaml:"sessionSecret" toml:"sessionSecret"`
+}
+
+// snapshot is the immutable value layered by mergeFile/mergeEnv/mergeFlags.
+// FilmsConfig swaps an atomic pointer to a new snapshot on SIGHUP instead
+// of mutating fields in place, so concurrent readers never observe a
+// partially-applied reload.
+type snapshot struct {
+  DB    DatabaseConfig `json:"db" yaml:"db" toml:"db"`
+  HTTP  HTTPConfig     `json:"http" yaml:"http" toml:"http"`
+  Log   LogConfig      `json:"log" yaml:"log" toml:"log"`
+  Cache CacheConfig    `json:"cache" yaml:"cache" toml:"cache"`
+  Auth  AuthConfig     `json:"auth" yaml:"auth" toml:"auth"`
+}
+
+// FilmsConfig is the root configuration object for FilmsApp. It holds the
+// current snapshot of per-subsystem sub-structs, read through the typed
+// accessors below; future subsystems belong in snapshot as well.
+type FilmsConfig struct {
+  v        atomic.Value // holds *snapshot
+  filePath string
+}
+
+func defaultSnapshot() *snapshot {
+  return &snapshot{
+    DB: DatabaseConfig{
+      Username: "root",
+      Password: "",
+      Database: "pavedroad",
+      SSLMode:  "disable",
+      DBDriver: "postgres",
+      IP:       "127.0.0.1",
+      Port:     "26257",
+    },
+    HTTP: HTTPConfig{
+      IP:              "127.0.0.1",
+      Port:            "8082",
+      ShutdownTimeout: 15 * time.Second,
+      ReadTimeout:     60 * time.Second,
+      WriteTimeout:    60 * time.Second,
+      ListenString:    "127.0.0.1:8082",
+      LogPath:         "logs/films.log",
+    },
+    Log: LogConfig{
+      Level:      "info",
+      Format:     "text",
+      MaxSize:    100,
+      MaxAge:     28,
+      MaxBackups: 3,
+      Compress:   false,
+    },
+    Cache: CacheConfig{
+      Host: "127.0.0.1",
+      Port: "6379",
+      TTL:  5 * time.Minute,
+    },
+    Auth: AuthConfig{
+      SessionSecret: "",
+    },
+  }
+}
+
+// Default returns a FilmsConfig seeded with the same defaults the old
+// dbconf/httpconf globals used
+func Default() *FilmsConfig {
+  c := &FilmsConfig{}
+  c.v.Store(defaultSnapshot())
+  return c
+}
+
+// Load resolves a FilmsConfig by layering struct defaults, an optional
+// config file (format is auto-detected from its extension), environment
+// variables (prefixed with FILMS_, apart from the CACHE_* settings), and
+// command-line flags, in that order
+func Load(configPath string, args []string) (*FilmsConfig, error) {
+  s := defaultSnapshot()
+
+  if configPath != "" {
+    if err := mergeFile(s, configPath); err != nil {
+      return nil, err
+    }
+  }
+
+  mergeEnv(s)
+
+  if err := mergeFlags(s, args); err != nil {
+    return nil, err
+  }
+
+  c := &FilmsConfig{filePath: configPath}
+  c.v.Store(s)
+  return c, nil
+}
+
+// mergeFile loads configPath and overlays it onto s. JSON, YAML, and TOML
+// are detected by file extension; an unrecognized extension is an error
+func mergeFile(s *snapshot, configPath string) error {
+  data, err := os.ReadFile(configPath)
+  if err != nil {
+    return fmt.Errorf("config: reading %s: %w", configPath, err)
+  }
+
+  switch strings.ToLower(filepath.Ext(configPath)) {
+  case ".json":
+    return json.Unmarshal(data, s)
+  case ".yaml", ".yml":
+    return yaml.Unmarshal(data, s)
+  case ".toml":
+    return toml.Unmarshal(data, s)
+  default:
+    return fmt.Errorf("config: unsupported config file extension %q", filepath.Ext(configPath))
+  }
+}
+
+// mergeEnv overlays FILMS_-prefixed environment variables, and the
+// unprefixed CACHE_HOST, CACHE_PORT, CACHE_PASSWORD and CACHE_TTL, onto s
+func mergeEnv(s *snapshot) {
+  overlayString(&s.DB.Username, EnvPrefix+"DB_USERNAME")
+  overlayString(&s.DB.Password, EnvPrefix+"DB_PASSWORD")
+  overlayString(&s.DB.Database, EnvPrefix+"DB_DATABASE")
+  overlayString(&s.DB.SSLMode, EnvPrefix+"DB_SSLMODE")
+  overlayString(&s.DB.DBDriver, EnvPrefix+"DB_DRIVER")
+  overlayString(&s.DB.IP, EnvPrefix+"DB_IP")
+  overlayString(&s.DB.Port, EnvPrefix+"DB_PORT")
+
+  overlayString(&s.HTTP.IP, EnvPrefix+"HTTP_IP")
+  overlayString(&s.HTTP.Port, EnvPrefix+"HTTP_PORT")
+  overlayString(&s.HTTP.ListenString, EnvPrefix+"HTTP_LISTEN")
+  overlayString(&s.HTTP.LogPath, EnvPrefix+"HTTP_LOGPATH")
+  overlayDuration(&s.HTTP.ShutdownTimeout, EnvPrefix+"HTTP_SHUTDOWN_TIMEOUT")
+  overlayDuration(&s.HTTP.ReadTimeout, EnvPrefix+"HTTP_READ_TIMEOUT")
+  overlayDuration(&s.HTTP.WriteTimeout, EnvPrefix+"HTTP_WRITE_TIMEOUT")
+
+  overlayString(&s.Log.Level, EnvPrefix+"LOG_LEVEL")
+  overlayString(&s.Log.Format, EnvPrefix+"LOG_FORMAT")
+
+  overlayString(&s.Cache.Host, "CACHE_HOST")
+  overlayString(&s.Cache.Port, "CACHE_PORT")
+  overlayString(&s.Cache.Password, "CACHE_PASSWORD")
+  overlayDuration(&s.Cache.TTL, "CACHE_TTL")
+
+  overlayString(&s.Auth.SessionSecret, EnvPrefix+"AUTH_SESSION_SECRET")
+}
+
+// mergeFlags overlays command-line flags onto s. Flags take precedence
+// over everything else
+func mergeFlags(s *snapshot, args []string) error {
+  fs := flag.NewFlagSet("films", flag.ContinueOnError)
+  dbIP := fs.String("db-ip", s.DB.IP, "database host")
+  dbPort := fs.String("db-port", s.DB.Port, "database port")
+  httpListen := fs.String("listen", s.HTTP.ListenString, "http listen address")
+  logFormat := fs.String("log-format", s.Log.Format, "log output format: text|json")
+
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  s.DB.IP = *dbIP
+  s.DB.Port = *dbPort
+  s.HTTP.ListenString = *httpListen
+  s.Log.Format = *logFormat
+  return nil
+}
+
+// WatchSIGHUP re-reads the backing config file and merges env/flags again
+// whenever the process receives SIGHUP, so operators can push config
+// changes without a restart. Each reload builds a brand new snapshot and
+// swaps it in atomically; readers never see a partially-applied reload.
+func (c *FilmsConfig) WatchSIGHUP(args []string) {
+  if c.filePath == "" {
+    return
+  }
+
+  sig := make(chan os.Signal, 1)
+  signal.Notify(sig, syscall.SIGHUP)
+  go func() {
+    for range sig {
+      next := defaultSnapshot()
+      if err := mergeFile(next, c.filePath); err != nil {
+        continue
+      }
+      mergeEnv(next)
+      if err := mergeFlags(next, args); err != nil {
+        continue
+      }
+      c.v.Store(next)
+    }
+  }()
+}
+
+func (c *FilmsConfig) current() *snapshot {
+  return c.v.Load().(*snapshot)
+}
+
+// DB returns a copy of the current database configuration, safe to call
+// while a SIGHUP reload is in flight
+func (c *FilmsConfig) DB() DatabaseConfig {
+  return c.current().DB
+}
+
+// HTTP returns a copy of the current HTTP configuration, safe to call
+// while a SIGHUP reload is in flight
+func (c *FilmsConfig) HTTP() HTTPConfig {
+  return c.current().HTTP
+}
+
+// Log returns a copy of the current logging configuration, safe to call
+// while a SIGHUP reload is in flight
+func (c *FilmsConfig) Log() LogConfig {
+  return c.current().Log
+}
+
+// Cache returns a copy of the current cache configuration, safe to call
+// while a SIGHUP reload is in flight
+func (c *FilmsConfig) Cache() CacheConfig {
+  return c.current().Cache
+}
+
+// Auth returns a copy of the current auth configuration, safe to call
+// while a SIGHUP reload is in flight
+func (c *FilmsConfig) Auth() AuthConfig {
+  return c.current().Auth
+}
+
+// DBIP returns the configured database host, safe to call while a SIGHUP
+// reload is in flight
+func (c *FilmsConfig) DBIP() string {
+  return c.current().DB.IP
+}
+
+// ListenString returns the configured HTTP listen address, safe to call
+// while a SIGHUP reload is in flight
+func (c *FilmsConfig) ListenString() string {
+  return c.current().HTTP.ListenString
+}
+
+// DSN builds a postgres-style connection string suitable for
+// sql.Open(db.DBDriver, ...) from db
+func DSN(db DatabaseConfig) string {
+  return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
+    url.QueryEscape(db.Username), url.QueryEscape(db.Password), db.IP, db.Port, db.Database, db.SSLMode)
+}
+
+func overlayString(dst *string, key string) {
+  if v, ok := os.LookupEnv(key); ok {
+    *dst = v
+  }
+}
+
+func overlayDuration(dst *time.Duration, key string) {
+  v, ok := os.LookupEnv(key)
+  if !ok {
+    return
+  }
+  if d, err := time.ParseDuration(v); err == nil {
+    *dst = d
+  } else if secs, err := strconv.Atoi(v); err == nil {
+    *dst = time.Duration(secs) * time.Second
+  }
+}
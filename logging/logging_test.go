@@ -0,0 +1,55 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+package logging
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "testing"
+
+  "github.com/gorilla/mux"
+  "go.uber.org/zap"
+  "go.uber.org/zap/zapcore"
+  "go.uber.org/zap/zaptest/observer"
+)
+
+func TestMiddlewareLogsRequestFields(t *testing.T) {
+  core, logs := observer.New(zapcore.InfoLevel)
+  logger := zap.New(core)
+
+  router := mux.NewRouter()
+  router.Use(Middleware(logger))
+  router.HandleFunc("/films/{key}", func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusCreated)
+  })
+
+  req := httptest.NewRequest(http.MethodPost, "/films/space-odyssey", nil)
+  req.Header.Set("X-Request-Id", "req-123")
+  router.ServeHTTP(httptest.NewRecorder(), req)
+
+  entries := logs.All()
+  if len(entries) != 1 {
+    t.Fatalf("expected 1 log entry, got %d", len(entries))
+  }
+
+  fields := entries[0].ContextMap()
+  if fields["request_id"] != "req-123" {
+    t.Errorf("request_id = %v, want %q", fields["request_id"], "req-123")
+  }
+  if fields["method"] != http.MethodPost {
+    t.Errorf("method = %v, want %q", fields["method"], http.MethodPost)
+  }
+  if fields["path"] != "/films/space-odyssey" {
+    t.Errorf("path = %v, want %q", fields["path"], "/films/space-odyssey")
+  }
+  if fields["user_key"] != "space-odyssey" {
+    t.Errorf("user_key = %v, want %q", fields["user_key"], "space-odyssey")
+  }
+  if fields["status"] != int64(http.StatusCreated) {
+    t.Errorf("status = %v, want %d", fields["status"], http.StatusCreated)
+  }
+}
@@ -0,0 +1,187 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+// User project / copyright / usage information
+// Prometheus metrics plus /healthz and /readyz probes, matching the
+// metric shapes MinIO exposes
+
+package metrics
+
+import (
+  "context"
+  "database/sql"
+  "net/http"
+  "time"
+
+  "github.com/gorilla/mux"
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/promauto"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Checker reports whether a FilmsApp subsystem is currently healthy, so
+// Readyz can poll the cache and job queue without importing them
+type Checker interface {
+  Healthy(ctx context.Context) bool
+}
+
+// pinger is satisfied by *sql.DB.PingContext; readyz takes it as a plain
+// func so its pass/fail decision can be exercised in tests without a
+// live database
+type pinger func(ctx context.Context) error
+
+// Metrics holds the process-wide Prometheus collectors registered on
+// FilmsApp.Router
+type Metrics struct {
+  requestsTotal   *prometheus.CounterVec
+  requestDuration *prometheus.HistogramVec
+  cacheRequests   *prometheus.CounterVec
+  dbInUse         prometheus.Gauge
+  dbIdle          prometheus.Gauge
+  dbWaitCount     prometheus.Gauge
+  startTime       time.Time
+}
+
+// New registers the collectors with the default Prometheus registry
+func New() *Metrics {
+  m := &Metrics{
+    requestsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+      Name: "films_requests_total",
+      Help: "Total HTTP requests by route and status",
+    }, []string{"route", "method", "status"}),
+    requestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+      Name:    "films_request_duration_seconds",
+      Help:    "HTTP request latency by route",
+      Buckets: prometheus.DefBuckets,
+    }, []string{"route", "method"}),
+    cacheRequests: promauto.NewCounterVec(prometheus.CounterOpts{
+      Name: "films_cache_requests_total",
+      Help: "Total cache lookups by result (hit or miss)",
+    }, []string{"result"}),
+    dbInUse: promauto.NewGauge(prometheus.GaugeOpts{
+      Name: "films_db_connections_in_use",
+      Help: "Database connections currently in use",
+    }),
+    dbIdle: promauto.NewGauge(prometheus.GaugeOpts{
+      Name: "films_db_connections_idle",
+      Help: "Database connections currently idle",
+    }),
+    dbWaitCount: promauto.NewGauge(prometheus.GaugeOpts{
+      Name: "films_db_connections_wait_count",
+      Help: "Total number of connections waited for",
+    }),
+    startTime: time.Now(),
+  }
+
+  promauto.NewGaugeFunc(prometheus.GaugeOpts{
+    Name: "films_process_start_time_seconds",
+    Help: "Process start time, in seconds since the Unix epoch",
+  }, m.StartTimeSeconds)
+  promauto.NewGaugeFunc(prometheus.GaugeOpts{
+    Name: "films_process_uptime_seconds",
+    Help: "Seconds the process has been running",
+  }, m.UptimeSeconds)
+
+  return m
+}
+
+// Hit records a cache hit, implementing cache.Recorder
+func (m *Metrics) Hit() {
+  m.cacheRequests.WithLabelValues("hit").Inc()
+}
+
+// Miss records a cache miss, implementing cache.Recorder
+func (m *Metrics) Miss() {
+  m.cacheRequests.WithLabelValues("miss").Inc()
+}
+
+// Instrument wraps next so every request through it updates
+// requestsTotal and requestDuration, keyed by the matched mux route
+func (m *Metrics) Instrument(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    start := time.Now()
+    rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+    next.ServeHTTP(rec, r)
+
+    route := "unmatched"
+    if rt := mux.CurrentRoute(r); rt != nil {
+      if tmpl, err := rt.GetPathTemplate(); err == nil {
+        route = tmpl
+      }
+    }
+
+    m.requestsTotal.WithLabelValues(route, r.Method, http.StatusText(rec.status)).Inc()
+    m.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+  })
+}
+
+// Register adds /metrics, /healthz, and /readyz to router
+func (m *Metrics) Register(router *mux.Router, db *sql.DB, deps ...Checker) {
+  router.Handle("/metrics", promhttp.Handler())
+  router.HandleFunc("/healthz", m.healthz)
+  router.HandleFunc("/readyz", m.readyz(db, deps))
+}
+
+// healthz is a cheap in-process liveness check
+func (m *Metrics) healthz(w http.ResponseWriter, r *http.Request) {
+  w.WriteHeader(http.StatusOK)
+  w.Write([]byte("ok"))
+}
+
+// readyz verifies the DB, and any configured cache/job-queue Checkers,
+// are reachable before reporting ready
+func (m *Metrics) readyz(db *sql.DB, deps []Checker) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    ready, reason := evaluateReadiness(r.Context(), db.PingContext, deps)
+    if !ready {
+      http.Error(w, reason, http.StatusServiceUnavailable)
+      return
+    }
+
+    stats := db.Stats()
+    m.dbInUse.Set(float64(stats.InUse))
+    m.dbIdle.Set(float64(stats.Idle))
+    m.dbWaitCount.Set(float64(stats.WaitCount))
+
+    w.WriteHeader(http.StatusOK)
+    w.Write([]byte("ok"))
+  }
+}
+
+// evaluateReadiness is the pure decision behind readyz: the DB must be
+// reachable and every dep must report healthy
+func evaluateReadiness(ctx context.Context, ping pinger, deps []Checker) (bool, string) {
+  if err := ping(ctx); err != nil {
+    return false, "db unreachable"
+  }
+  for _, dep := range deps {
+    if !dep.Healthy(ctx) {
+      return false, "dependency unhealthy"
+    }
+  }
+  return true, ""
+}
+
+// StartTimeSeconds returns the process start time in seconds since the
+// Unix epoch, mirroring MinIO's process_start_time_seconds
+func (m *Metrics) StartTimeSeconds() float64 {
+  return float64(m.startTime.Unix())
+}
+
+// UptimeSeconds returns how long the process has been running
+func (m *Metrics) UptimeSeconds() float64 {
+  return time.Since(m.startTime).Seconds()
+}
+
+type statusRecorder struct {
+  http.ResponseWriter
+  status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+  r.status = status
+  r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,69 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+package metrics
+
+import (
+  "context"
+  "errors"
+  "testing"
+
+  "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeChecker struct{ healthy bool }
+
+func (f fakeChecker) Healthy(ctx context.Context) bool { return f.healthy }
+
+func TestEvaluateReadinessDBUnreachable(t *testing.T) {
+  ping := func(ctx context.Context) error { return errors.New("no route to host") }
+
+  ready, reason := evaluateReadiness(context.Background(), ping, nil)
+  if ready {
+    t.Error("expected not ready when db ping fails")
+  }
+  if reason != "db unreachable" {
+    t.Errorf("reason = %q, want %q", reason, "db unreachable")
+  }
+}
+
+func TestEvaluateReadinessDependencyUnhealthy(t *testing.T) {
+  ping := func(ctx context.Context) error { return nil }
+  deps := []Checker{fakeChecker{healthy: true}, fakeChecker{healthy: false}}
+
+  ready, reason := evaluateReadiness(context.Background(), ping, deps)
+  if ready {
+    t.Error("expected not ready when a dependency reports unhealthy")
+  }
+  if reason != "dependency unhealthy" {
+    t.Errorf("reason = %q, want %q", reason, "dependency unhealthy")
+  }
+}
+
+func TestEvaluateReadinessAllHealthy(t *testing.T) {
+  ping := func(ctx context.Context) error { return nil }
+  deps := []Checker{fakeChecker{healthy: true}, fakeChecker{healthy: true}}
+
+  ready, reason := evaluateReadiness(context.Background(), ping, deps)
+  if !ready {
+    t.Errorf("expected ready, got reason %q", reason)
+  }
+}
+
+func TestMetricsHitMissIncrementCacheRequests(t *testing.T) {
+  m := New()
+
+  m.Hit()
+  m.Miss()
+  m.Miss()
+
+  if got := testutil.ToFloat64(m.cacheRequests.WithLabelValues("hit")); got != 1 {
+    t.Errorf("hit count = %v, want 1", got)
+  }
+  if got := testutil.ToFloat64(m.cacheRequests.WithLabelValues("miss")); got != 2 {
+    t.Errorf("miss count = %v, want 2", got)
+  }
+}
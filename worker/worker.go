@@ -0,0 +1,250 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+// User project / copyright / usage information
+// Background job queue for long-running film enrichment tasks (poster
+// art, metadata transcoding, catalog imports), backed by the jobs table
+// and SELECT ... FOR UPDATE SKIP LOCKED so multiple workers can safely
+// poll the same database
+
+package worker
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "time"
+)
+
+// Status values recorded in the jobs table
+const (
+  StatusPending = "pending"
+  StatusRunning = "running"
+  StatusDone    = "done"
+  StatusFailed  = "failed"
+)
+
+// MaxAttempts caps how many times a job is attempted in all before it is
+// left in StatusFailed for good
+const MaxAttempts = 5
+
+// Job is a row in the jobs table
+type Job struct {
+  ID       int64
+  Type     string
+  Payload  json.RawMessage
+  Status   string
+  Attempts int
+  Result   json.RawMessage
+  Error    string
+}
+
+// Queue is the interface FilmsApp depends on for enqueuing work and the
+// cmd/worker binary depends on for pulling it
+type Queue interface {
+  Enqueue(ctx context.Context, jobType string, payload interface{}) (int64, error)
+  Next(ctx context.Context) (*Job, error)
+  Get(ctx context.Context, id int64) (*Job, error)
+  MarkDone(ctx context.Context, id int64, result interface{}) error
+  MarkFailed(ctx context.Context, id int64, cause error) error
+  // Healthy reports whether the queue's backing store can currently be
+  // reached, so it can be plugged into metrics.Register as a Checker
+  Healthy(ctx context.Context) bool
+}
+
+// SQLQueue implements Queue on top of the same SQL DB FilmsApp already
+// uses, so API pods and worker pods can be split across k8s deployments
+// without standing up a separate broker
+type SQLQueue struct {
+  db *sql.DB
+}
+
+// New wraps db as a Queue. The jobs table is expected to already exist
+// (id bigserial pk, type text, payload jsonb, status text, attempts int,
+// result jsonb, error text, created_at, updated_at)
+func New(db *sql.DB) *SQLQueue {
+  return &SQLQueue{db: db}
+}
+
+// Enqueue inserts a new pending job and returns its id
+func (q *SQLQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) (int64, error) {
+  data, err := json.Marshal(payload)
+  if err != nil {
+    return 0, fmt.Errorf("worker: marshal payload: %w", err)
+  }
+
+  var id int64
+  err = q.db.QueryRowContext(ctx,
+    `INSERT INTO jobs (type, payload, status, attempts, created_at, updated_at)
+     VALUES ($1, $2, $3, 0, now(), now()) RETURNING id`,
+    jobType, data, StatusPending,
+  ).Scan(&id)
+  if err != nil {
+    return 0, fmt.Errorf("worker: enqueue: %w", err)
+  }
+  return id, nil
+}
+
+// Next claims the oldest pending job using SELECT ... FOR UPDATE SKIP
+// LOCKED so concurrent workers never double-claim the same row
+func (q *SQLQueue) Next(ctx context.Context) (*Job, error) {
+  tx, err := q.db.BeginTx(ctx, nil)
+  if err != nil {
+    return nil, err
+  }
+  defer tx.Rollback()
+
+  var j Job
+  err = tx.QueryRowContext(ctx,
+    `SELECT id, type, payload, status, attempts FROM jobs
+     WHERE status = $1 ORDER BY created_at FOR UPDATE SKIP LOCKED LIMIT 1`,
+    StatusPending,
+  ).Scan(&j.ID, &j.Type, &j.Payload, &j.Status, &j.Attempts)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, fmt.Errorf("worker: next: %w", err)
+  }
+
+  if _, err := tx.ExecContext(ctx,
+    `UPDATE jobs SET status = $1, attempts = attempts + 1, updated_at = now() WHERE id = $2`,
+    StatusRunning, j.ID,
+  ); err != nil {
+    return nil, fmt.Errorf("worker: claim: %w", err)
+  }
+
+  if err := tx.Commit(); err != nil {
+    return nil, err
+  }
+  j.Status = StatusRunning
+  j.Attempts++
+  return &j, nil
+}
+
+// Healthy pings the backing database so the readiness probe can report
+// job-queue status
+func (q *SQLQueue) Healthy(ctx context.Context) bool {
+  return q.db.PingContext(ctx) == nil
+}
+
+// Get returns the current state of job id, used by the /api/v1/jobs/{id}
+// status endpoint
+func (q *SQLQueue) Get(ctx context.Context, id int64) (*Job, error) {
+  var j Job
+  err := q.db.QueryRowContext(ctx,
+    `SELECT id, type, payload, status, attempts, COALESCE(result, 'null'), COALESCE(error, '') FROM jobs WHERE id = $1`,
+    id,
+  ).Scan(&j.ID, &j.Type, &j.Payload, &j.Status, &j.Attempts, &j.Result, &j.Error)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, fmt.Errorf("worker: get %d: %w", id, err)
+  }
+  return &j, nil
+}
+
+// MarkDone records a successful result
+func (q *SQLQueue) MarkDone(ctx context.Context, id int64, result interface{}) error {
+  data, err := json.Marshal(result)
+  if err != nil {
+    return fmt.Errorf("worker: marshal result: %w", err)
+  }
+  _, err = q.db.ExecContext(ctx,
+    `UPDATE jobs SET status = $1, result = $2, updated_at = now() WHERE id = $3`,
+    StatusDone, data, id,
+  )
+  return err
+}
+
+// MarkFailed records cause against the job. Once attempts has reached
+// MaxAttempts the job is left in StatusFailed; otherwise it is put back
+// to StatusPending so it is retried with backoff by the caller
+func (q *SQLQueue) MarkFailed(ctx context.Context, id int64, cause error) error {
+  var attempts int
+  if err := q.db.QueryRowContext(ctx, `SELECT attempts FROM jobs WHERE id = $1`, id).Scan(&attempts); err != nil {
+    return err
+  }
+
+  _, err := q.db.ExecContext(ctx,
+    `UPDATE jobs SET status = $1, error = $2, updated_at = now() WHERE id = $3`,
+    nextFailedStatus(attempts), cause.Error(), id,
+  )
+  return err
+}
+
+// nextFailedStatus decides whether a failed job is retried (StatusPending)
+// or permanently abandoned (StatusFailed) based on how many attempts it
+// has already used
+func nextFailedStatus(attempts int) string {
+  if attempts >= MaxAttempts {
+    return StatusFailed
+  }
+  return StatusPending
+}
+
+// Handler processes a single job's payload and returns a result to store
+type Handler func(ctx context.Context, job *Job) (interface{}, error)
+
+// Worker polls Queue for jobs and dispatches them to registered Handlers
+type Worker struct {
+  Queue        Queue
+  PollInterval time.Duration
+  handlers     map[string]Handler
+}
+
+// NewWorker builds a Worker polling q every pollInterval
+func NewWorker(q Queue, pollInterval time.Duration) *Worker {
+  return &Worker{Queue: q, PollInterval: pollInterval, handlers: map[string]Handler{}}
+}
+
+// Register associates jobType with a Handler
+func (w *Worker) Register(jobType string, h Handler) {
+  w.handlers[jobType] = h
+}
+
+// Run polls until ctx is cancelled, executing handlers after a backoff
+// that grows linearly with the job's attempt count (one second per
+// attempt, including the first)
+func (w *Worker) Run(ctx context.Context) {
+  ticker := time.NewTicker(w.PollInterval)
+  defer ticker.Stop()
+
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case <-ticker.C:
+      w.runOnce(ctx)
+    }
+  }
+}
+
+func (w *Worker) runOnce(ctx context.Context) {
+  job, err := w.Queue.Next(ctx)
+  if err != nil || job == nil {
+    return
+  }
+
+  h, ok := w.handlers[job.Type]
+  if !ok {
+    w.Queue.MarkFailed(ctx, job.ID, fmt.Errorf("worker: no handler registered for job type %q", job.Type))
+    return
+  }
+
+  backoff := time.Duration(job.Attempts) * time.Second
+  time.Sleep(backoff)
+
+  result, err := h(ctx, job)
+  if err != nil {
+    w.Queue.MarkFailed(ctx, job.ID, err)
+    return
+  }
+  w.Queue.MarkDone(ctx, job.ID, result)
+}
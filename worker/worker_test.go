@@ -0,0 +1,99 @@
+
+//
+// Copyright (c) PavedRoad. All rights reserved.
+// Licensed under the Apache2. See LICENSE file in the project root for full license information.
+//
+
+package worker
+
+import (
+  "context"
+  "errors"
+  "testing"
+)
+
+func TestNextFailedStatus(t *testing.T) {
+  cases := []struct {
+    attempts int
+    want     string
+  }{
+    {0, StatusPending},
+    {MaxAttempts - 1, StatusPending},
+    {MaxAttempts, StatusFailed},
+    {MaxAttempts + 1, StatusFailed},
+  }
+  for _, c := range cases {
+    if got := nextFailedStatus(c.attempts); got != c.want {
+      t.Errorf("nextFailedStatus(%d) = %q, want %q", c.attempts, got, c.want)
+    }
+  }
+}
+
+// fakeQueue is an in-memory Queue used to exercise Worker dispatch logic
+// without a real database
+type fakeQueue struct {
+  job    *Job
+  done   bool
+  failed error
+}
+
+func (q *fakeQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) (int64, error) {
+  return 1, nil
+}
+func (q *fakeQueue) Next(ctx context.Context) (*Job, error) { return q.job, nil }
+func (q *fakeQueue) Get(ctx context.Context, id int64) (*Job, error) { return q.job, nil }
+func (q *fakeQueue) MarkDone(ctx context.Context, id int64, result interface{}) error {
+  q.done = true
+  return nil
+}
+func (q *fakeQueue) MarkFailed(ctx context.Context, id int64, cause error) error {
+  q.failed = cause
+  return nil
+}
+func (q *fakeQueue) Healthy(ctx context.Context) bool { return true }
+
+func TestWorkerRunOnceMarksDoneOnSuccess(t *testing.T) {
+  q := &fakeQueue{job: &Job{ID: 1, Type: "poster_art"}}
+  w := NewWorker(q, 0)
+  w.Register("poster_art", func(ctx context.Context, job *Job) (interface{}, error) {
+    return "ok", nil
+  })
+
+  w.runOnce(context.Background())
+
+  if !q.done {
+    t.Error("expected job to be marked done")
+  }
+  if q.failed != nil {
+    t.Errorf("expected no failure, got %v", q.failed)
+  }
+}
+
+func TestWorkerRunOnceMarksFailedOnUnknownType(t *testing.T) {
+  q := &fakeQueue{job: &Job{ID: 1, Type: "unknown"}}
+  w := NewWorker(q, 0)
+
+  w.runOnce(context.Background())
+
+  if q.failed == nil {
+    t.Error("expected job to be marked failed for an unregistered type")
+  }
+}
+
+func TestWorkerRunOnceMarksFailedOnHandlerError(t *testing.T) {
+  q := &fakeQueue{job: &Job{ID: 1, Type: "poster_art"}}
+  w := NewWorker(q, 0)
+  wantErr := errors.New("boom")
+  w.Register("poster_art", func(ctx context.Context, job *Job) (interface{}, error) {
+    return nil, wantErr
+  })
+
+  w.runOnce(context.Background())
+
+  if q.failed == nil {
+    t.Fatal("expected job to be marked failed")
+  }
+  if !q.done && q.failed != wantErr {
+    t.Errorf("failed = %v, want %v", q.failed, wantErr)
+  }
+}